@@ -0,0 +1,52 @@
+// Command gosocks runs a standalone SOCKS5 proxy server.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jflyup/gosocks/socks5"
+)
+
+func main() {
+	addr := flag.String("listen", ":1080", "address to listen on")
+	credFile := flag.String("credentials", "", `path to a "user:pass" per line file enabling username/password authentication`)
+	logFile := flag.String("log", "proxy.log", "path to the log file")
+	dialTimeout := flag.Duration("dial-timeout", 10*time.Second, "timeout for outbound CONNECT dials")
+	enableBind := flag.Bool("enable-bind", false, "allow the BIND command (rarely safe on public deployments)")
+	flag.Parse()
+
+	logOut, err := os.OpenFile(*logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		log.Fatal("error opening log file: ", err)
+	}
+	defer logOut.Close()
+	logger := log.New(logOut, "", log.LstdFlags|log.Lshortfile)
+
+	config := &socks5.Config{
+		Logger:      logger,
+		DialTimeout: *dialTimeout,
+		EnableBind:  *enableBind,
+	}
+
+	if *credFile != "" {
+		store, err := socks5.LoadCredentialFile(*credFile)
+		if err != nil {
+			log.Fatal("error loading credentials: ", err)
+		}
+		// Only offer UserPassAuthenticator: Server picks the first configured
+		// method the client also advertises, so listing NoAuthAuthenticator
+		// alongside it would let any client offering method 0x00 skip the
+		// password check entirely.
+		config.Authenticators = []socks5.Authenticator{
+			socks5.UserPassAuthenticator{Credentials: store},
+		}
+	}
+
+	server := socks5.New(config)
+	if err := server.ListenAndServe("tcp", *addr); err != nil {
+		log.Fatal("listen error: ", err)
+	}
+}
@@ -0,0 +1,135 @@
+package socks5
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	noAuthRequired      uint8 = 0x00
+	userPassAuth        uint8 = 0x02
+	noAcceptableMethods uint8 = 0xFF
+)
+
+// AuthContext carries the outcome of the method-specific sub-negotiation
+// down to request handling, so per-user rules can later be applied.
+type AuthContext struct {
+	Method   uint8
+	Username string
+}
+
+// Authenticator negotiates one SOCKS5 authentication method (RFC 1928
+// section 3) over the already-accepted connection.
+type Authenticator interface {
+	GetCode() uint8
+	Authenticate(reader io.Reader, writer io.Writer) (*AuthContext, error)
+}
+
+// NoAuthAuthenticator implements the "no authentication required" method.
+type NoAuthAuthenticator struct{}
+
+func (a NoAuthAuthenticator) GetCode() uint8 {
+	return noAuthRequired
+}
+
+func (a NoAuthAuthenticator) Authenticate(reader io.Reader, writer io.Writer) (*AuthContext, error) {
+	_, err := writer.Write([]byte{5, noAuthRequired})
+	return &AuthContext{Method: noAuthRequired}, err
+}
+
+// CredentialStore validates username/password pairs for UserPassAuthenticator.
+type CredentialStore interface {
+	Valid(user, pass string) bool
+}
+
+// UserPassAuthenticator implements RFC 1929 username/password authentication.
+type UserPassAuthenticator struct {
+	Credentials CredentialStore
+}
+
+func (a UserPassAuthenticator) GetCode() uint8 {
+	return userPassAuth
+}
+
+func (a UserPassAuthenticator) Authenticate(reader io.Reader, writer io.Writer) (*AuthContext, error) {
+	if _, err := writer.Write([]byte{5, userPassAuth}); err != nil {
+		return nil, err
+	}
+
+	/*
+		+----+------+----------+------+----------+
+		|VER | ULEN |  UNAME   | PLEN |  PASSWD  |
+		+----+------+----------+------+----------+
+		| 1  |  1   | 1 to 255 |  1   | 1 to 255 |
+		+----+------+----------+------+----------+
+	*/
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	if header[0] != 1 {
+		return nil, errors.New("unsupported user/pass negotiation version")
+	}
+
+	uname := make([]byte, header[1])
+	if _, err := io.ReadFull(reader, uname); err != nil {
+		return nil, err
+	}
+
+	plen := make([]byte, 1)
+	if _, err := io.ReadFull(reader, plen); err != nil {
+		return nil, err
+	}
+	passwd := make([]byte, plen[0])
+	if _, err := io.ReadFull(reader, passwd); err != nil {
+		return nil, err
+	}
+
+	if a.Credentials == nil || !a.Credentials.Valid(string(uname), string(passwd)) {
+		writer.Write([]byte{1, 1})
+		return nil, errors.New("invalid username or password")
+	}
+
+	if _, err := writer.Write([]byte{1, 0}); err != nil {
+		return nil, err
+	}
+	return &AuthContext{Method: userPassAuth, Username: string(uname)}, nil
+}
+
+// MapCredentialStore is a CredentialStore backed by an in-memory map of
+// username to password.
+type MapCredentialStore map[string]string
+
+func (m MapCredentialStore) Valid(user, pass string) bool {
+	p, ok := m[user]
+	return ok && p == pass
+}
+
+// LoadCredentialFile reads a MapCredentialStore from a file of "user:pass"
+// lines; blank lines and lines starting with # are ignored.
+func LoadCredentialFile(path string) (MapCredentialStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	store := MapCredentialStore{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("gosocks: invalid credentials line %q", line)
+		}
+		store[parts[0]] = parts[1]
+	}
+	return store, scanner.Err()
+}
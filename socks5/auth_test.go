@@ -0,0 +1,60 @@
+package socks5
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMapCredentialStoreValid(t *testing.T) {
+	store := MapCredentialStore{"alice": "wonderland"}
+
+	tests := []struct {
+		name, user, pass string
+		want             bool
+	}{
+		{"correct", "alice", "wonderland", true},
+		{"wrong password", "alice", "nope", false},
+		{"unknown user", "bob", "wonderland", false},
+		{"empty", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := store.Valid(tt.user, tt.pass); got != tt.want {
+				t.Errorf("Valid(%q, %q) = %v, want %v", tt.user, tt.pass, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadCredentialFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	contents := "# comment\n\nalice:wonderland\nbob:builder\n"
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	store, err := LoadCredentialFile(path)
+	if err != nil {
+		t.Fatalf("LoadCredentialFile() error: %v", err)
+	}
+
+	if !store.Valid("alice", "wonderland") || !store.Valid("bob", "builder") {
+		t.Errorf("LoadCredentialFile() = %v, missing expected entries", store)
+	}
+	if store.Valid("alice", "wrong") {
+		t.Errorf("LoadCredentialFile() accepted wrong password")
+	}
+}
+
+func TestLoadCredentialFileMalformedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(path, []byte("not-a-valid-line\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if _, err := LoadCredentialFile(path); err == nil {
+		t.Fatal("LoadCredentialFile() error = nil, want error for malformed line")
+	}
+}
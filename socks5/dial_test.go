@@ -0,0 +1,39 @@
+package socks5
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+)
+
+// fakeTimeoutErr is a minimal net.Error whose Timeout() is hardcoded, for
+// exercising mapDialError's timeout branch without a real dial.
+type fakeTimeoutErr struct{}
+
+func (fakeTimeoutErr) Error() string   { return "i/o timeout" }
+func (fakeTimeoutErr) Timeout() bool   { return true }
+func (fakeTimeoutErr) Temporary() bool { return true }
+
+func TestMapDialError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want uint8
+	}{
+		{"connection refused", &net.OpError{Err: syscall.ECONNREFUSED}, connectionRefused},
+		{"network unreachable", &net.OpError{Err: syscall.ENETUNREACH}, networkUnreachable},
+		{"host unreachable", &net.OpError{Err: syscall.EHOSTUNREACH}, hostUnreachable},
+		{"timeout", fakeTimeoutErr{}, TTLExpired},
+		{"unmapped errno falls back to hostUnreachable", &net.OpError{Err: syscall.EACCES}, hostUnreachable},
+		{"non-syscall error falls back to hostUnreachable", errors.New("boom"), hostUnreachable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapDialError(tt.err); got != tt.want {
+				t.Errorf("mapDialError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
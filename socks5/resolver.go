@@ -0,0 +1,25 @@
+package socks5
+
+import (
+	"context"
+	"net"
+)
+
+// NameResolver resolves a domain name to an IP address. It is invoked for
+// SOCKS5 requests that carry a DomainName address, letting callers substitute
+// DoH/DoT/DNSCrypt or userspace resolvers (e.g. a Tailscale-style resolver)
+// for the system's default one.
+type NameResolver interface {
+	Resolve(ctx context.Context, name string) (context.Context, net.IP, error)
+}
+
+// DefaultResolver resolves names using net.DefaultResolver.
+type DefaultResolver struct{}
+
+func (DefaultResolver) Resolve(ctx context.Context, name string) (context.Context, net.IP, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, name)
+	if err != nil {
+		return ctx, nil, err
+	}
+	return ctx, addrs[0].IP, nil
+}
@@ -0,0 +1,86 @@
+package socks5
+
+import (
+	"context"
+	"net"
+)
+
+// Request describes a single SOCKS5 request for RuleSet decisions.
+type Request struct {
+	Command     uint8
+	DestAddr    net.IP
+	DestPort    uint16
+	DestHost    string // the DST.ADDR as sent by the client, e.g. a domain name
+	AuthContext *AuthContext
+}
+
+// RuleSet decides whether a request may proceed. It is consulted after name
+// resolution and before the outbound dial, giving callers an extension point
+// for authz, split-tunnel, and loopback-blocking policies.
+type RuleSet interface {
+	Allow(ctx context.Context, req *Request) (context.Context, bool)
+}
+
+// PermitAll allows every request.
+type PermitAll struct{}
+
+func (PermitAll) Allow(ctx context.Context, req *Request) (context.Context, bool) {
+	return ctx, true
+}
+
+// PermitCommand allows only the listed SOCKS5 commands.
+type PermitCommand struct {
+	Connect   bool
+	Bind      bool
+	Associate bool
+}
+
+func (p PermitCommand) Allow(ctx context.Context, req *Request) (context.Context, bool) {
+	switch req.Command {
+	case Connect:
+		return ctx, p.Connect
+	case Bind:
+		return ctx, p.Bind
+	case Associate:
+		return ctx, p.Associate
+	default:
+		return ctx, false
+	}
+}
+
+// PermitDestAddr allows a request only if its destination falls within one of
+// Allowed and, when Ports is non-empty, its DST.PORT is one of Ports.
+type PermitDestAddr struct {
+	Allowed []*net.IPNet
+	Ports   []uint16
+}
+
+func (p PermitDestAddr) Allow(ctx context.Context, req *Request) (context.Context, bool) {
+	if len(p.Allowed) > 0 {
+		ok := false
+		for _, n := range p.Allowed {
+			if n.Contains(req.DestAddr) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return ctx, false
+		}
+	}
+
+	if len(p.Ports) > 0 {
+		ok := false
+		for _, port := range p.Ports {
+			if port == req.DestPort {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return ctx, false
+		}
+	}
+
+	return ctx, true
+}
@@ -0,0 +1,118 @@
+// Package socks5 implements a SOCKS5 (RFC 1928/1929) proxy server that can
+// be embedded in other Go programs, in the style of net/http's Server.
+package socks5
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+// Config configures a Server: which authentication methods it offers, how
+// it resolves and filters requests, how it dials outbound connections, and
+// where it logs. A zero Config is valid; New fills in defaults.
+type Config struct {
+	// Authenticators is the ordered list of methods the server will
+	// negotiate; the first one also advertised by the client wins.
+	// Defaults to []Authenticator{NoAuthAuthenticator{}}.
+	Authenticators []Authenticator
+
+	// Resolver resolves DomainName addresses. Defaults to DefaultResolver.
+	Resolver NameResolver
+
+	// Rules decides whether a resolved request may proceed. Defaults to
+	// PermitAll.
+	Rules RuleSet
+
+	// Dial opens outbound connections for CONNECT requests. Defaults to
+	// (&net.Dialer{}).DialContext.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// DialTimeout bounds outbound CONNECT dials. Defaults to 10s.
+	DialTimeout time.Duration
+
+	// Logger receives diagnostic output. Defaults to a logger on os.Stderr.
+	Logger *log.Logger
+
+	// BindIP is the local address used for relay sockets (UDP ASSOCIATE)
+	// and BIND listeners. The zero value binds to all interfaces.
+	BindIP net.IP
+
+	// EnableBind allows the RFC 1928 BIND command. It is rarely safe on
+	// public deployments, so it is disabled by default.
+	EnableBind bool
+
+	// BindAcceptTimeout bounds how long a BIND listener waits for the
+	// second incoming connection. Defaults to 2 minutes.
+	BindAcceptTimeout time.Duration
+}
+
+func (c *Config) setDefaults() {
+	if c.Authenticators == nil {
+		c.Authenticators = []Authenticator{NoAuthAuthenticator{}}
+	}
+	if c.Resolver == nil {
+		c.Resolver = DefaultResolver{}
+	}
+	if c.Rules == nil {
+		c.Rules = PermitAll{}
+	}
+	if c.Dial == nil {
+		c.Dial = (&net.Dialer{}).DialContext
+	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = 10 * time.Second
+	}
+	if c.Logger == nil {
+		c.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	if c.BindAcceptTimeout == 0 {
+		c.BindAcceptTimeout = 2 * time.Minute
+	}
+}
+
+// Server is a SOCKS5 proxy server.
+type Server struct {
+	config *Config
+}
+
+// New returns a Server configured by config. A nil config uses all defaults.
+func New(config *Config) *Server {
+	if config == nil {
+		config = &Config{}
+	}
+	config.setDefaults()
+	return &Server{config: config}
+}
+
+// ListenAndServe listens on network/addr and serves SOCKS5 connections until
+// the listener errors.
+func (s *Server) ListenAndServe(network, addr string) error {
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections from l, handling each in its own goroutine,
+// until Accept returns an error.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn handles a single already-accepted connection. It blocks until
+// the SOCKS5 session ends.
+func (s *Server) ServeConn(c net.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.handleNewConn(ctx, c)
+}
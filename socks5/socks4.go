@@ -0,0 +1,205 @@
+package socks5
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	socks4RequestGranted = 0x5A
+	socks4RequestFailed  = 0x5B
+)
+
+// handleSocks4 handles a legacy SOCKS4/4a request (VN already read as 4,
+// CD as cd) on the same listener as SOCKS5, for clients such as curl
+// --socks4 or older browsers.
+//
+//	+----+----+----+----+----+----+----+----+----+----+....+----+
+//	| VN | CD | DSTPORT |      DSTIP        | USERID       |NULL|
+//	+----+----+----+----+----+----+----+----+----+----+....+----+
+//	| 1  | 1  |    2    |         4         | variable     | 1  |
+//	+----+----+----+----+----+----+----+----+----+----+....+----+
+func (s *Server) handleSocks4(ctx context.Context, c net.Conn, cd byte) {
+	header := make([]byte, 6)
+	if _, err := io.ReadFull(c, header); err != nil {
+		s.config.Logger.Println("socks4: illegal request", err)
+		c.Close()
+		return
+	}
+	port := binary.BigEndian.Uint16(header[0:2])
+	dstIP := net.IP(header[2:6])
+
+	if _, err := readUntilNull(c); err != nil {
+		s.config.Logger.Println("socks4: illegal request", err)
+		c.Close()
+		return
+	}
+
+	host := dstIP.String()
+	atyp := uint8(IPv4)
+	// SOCKS4a: DSTIP of the form 0.0.0.x, x != 0, means the real
+	// destination is a domain name appended after USERID.
+	if dstIP[0] == 0 && dstIP[1] == 0 && dstIP[2] == 0 && dstIP[3] != 0 {
+		domain, err := readUntilNull(c)
+		if err != nil {
+			s.config.Logger.Println("socks4a: illegal request", err)
+			c.Close()
+			return
+		}
+		host = string(domain)
+		atyp = DomainName
+	}
+
+	switch cd {
+	case Connect:
+		s.handleSocks4Connect(ctx, c, atyp, host, port)
+	case Bind:
+		s.handleSocks4Bind(ctx, c, atyp, host, port)
+	default:
+		sendSocks4Reply(c, socks4RequestFailed, nil, 0)
+		c.Close()
+	}
+}
+
+func (s *Server) handleSocks4Connect(ctx context.Context, c net.Conn, atyp uint8, host string, port uint16) {
+	ip := net.ParseIP(host)
+	var err error
+	if atyp == DomainName {
+		ctx, ip, err = s.config.Resolver.Resolve(ctx, host)
+		if err != nil {
+			s.config.Logger.Println("socks4: fail to resolve", host, err)
+			sendSocks4Reply(c, socks4RequestFailed, nil, 0)
+			c.Close()
+			return
+		}
+	}
+
+	req := &Request{Command: Connect, DestAddr: ip, DestPort: port, DestHost: host}
+	var allowed bool
+	if ctx, allowed = s.config.Rules.Allow(ctx, req); !allowed {
+		sendSocks4Reply(c, socks4RequestFailed, nil, 0)
+		c.Close()
+		return
+	}
+
+	addr := net.JoinHostPort(ip.String(), strconv.Itoa(int(port)))
+
+	dialCtx, cancel := context.WithTimeout(ctx, s.config.DialTimeout)
+	defer cancel()
+	proxyConn, err := s.config.Dial(dialCtx, "tcp", addr)
+	if err != nil {
+		s.config.Logger.Println("socks4: fail to connect to", addr, err)
+		sendSocks4Reply(c, socks4RequestFailed, nil, 0)
+		c.Close()
+		return
+	}
+
+	sendSocks4Reply(c, socks4RequestGranted, nil, 0)
+	go relay(c, proxyConn)
+	go relay(proxyConn, c)
+}
+
+// handleSocks4Bind implements the SOCKS4/4a BIND command, mirroring
+// handleBind's RFC 1928 behavior: a listener is opened on the server's
+// outbound interface and its address returned in the first reply, then a
+// single peer connection is accepted within BindAcceptTimeout and its
+// address returned in the second reply before relaying begins. Gated
+// behind Server.EnableBind for the same reason as RFC 1928 BIND.
+func (s *Server) handleSocks4Bind(ctx context.Context, c net.Conn, atyp uint8, host string, port uint16) {
+	if !s.config.EnableBind {
+		sendSocks4Reply(c, socks4RequestFailed, nil, 0)
+		c.Close()
+		return
+	}
+
+	ip := net.ParseIP(host)
+	var err error
+	if atyp == DomainName {
+		ctx, ip, err = s.config.Resolver.Resolve(ctx, host)
+		if err != nil {
+			s.config.Logger.Println("socks4: fail to resolve", host, err)
+			sendSocks4Reply(c, socks4RequestFailed, nil, 0)
+			c.Close()
+			return
+		}
+	}
+
+	req := &Request{Command: Bind, DestAddr: ip, DestPort: port, DestHost: host}
+	var allowed bool
+	if ctx, allowed = s.config.Rules.Allow(ctx, req); !allowed {
+		sendSocks4Reply(c, socks4RequestFailed, nil, 0)
+		c.Close()
+		return
+	}
+
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: s.config.BindIP})
+	if err != nil {
+		s.config.Logger.Println("socks4: bind: failed to listen", err)
+		sendSocks4Reply(c, socks4RequestFailed, nil, 0)
+		c.Close()
+		return
+	}
+	defer l.Close()
+
+	bnd := l.Addr().(*net.TCPAddr)
+	sendSocks4Reply(c, socks4RequestGranted, bnd.IP, uint16(bnd.Port))
+
+	l.SetDeadline(time.Now().Add(s.config.BindAcceptTimeout))
+	peer, err := l.Accept()
+	if err != nil {
+		s.config.Logger.Println("socks4: bind: accept timed out", err)
+		sendSocks4Reply(c, socks4RequestFailed, nil, 0)
+		c.Close()
+		return
+	}
+
+	if ip != nil && !ip.IsUnspecified() {
+		peerAddr := peer.RemoteAddr().(*net.TCPAddr)
+		if !peerAddr.IP.Equal(ip) {
+			s.config.Logger.Println("socks4: bind: peer address mismatch", peerAddr.IP, "!=", ip)
+			sendSocks4Reply(c, socks4RequestFailed, nil, 0)
+			peer.Close()
+			c.Close()
+			return
+		}
+	}
+
+	peerAddr := peer.RemoteAddr().(*net.TCPAddr)
+	sendSocks4Reply(c, socks4RequestGranted, peerAddr.IP, uint16(peerAddr.Port))
+
+	go relay(c, peer)
+	go relay(peer, c)
+}
+
+// readUntilNull reads bytes up to and including a NUL terminator, returning
+// the bytes before it.
+func readUntilNull(r io.Reader) ([]byte, error) {
+	var buf []byte
+	one := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, one); err != nil {
+			return nil, err
+		}
+		if one[0] == 0 {
+			return buf, nil
+		}
+		buf = append(buf, one[0])
+	}
+}
+
+// sendSocks4Reply sends the 8-byte SOCKS4 response. The SOCKS4 spec treats
+// the DSTPORT/DSTIP reply fields as not meaningful for CONNECT, so callers
+// may pass a nil ip and zero port.
+func sendSocks4Reply(c net.Conn, cd byte, ip net.IP, port uint16) {
+	reply := make([]byte, 8)
+	reply[1] = cd
+	binary.BigEndian.PutUint16(reply[2:4], port)
+	if ip4 := ip.To4(); ip4 != nil {
+		copy(reply[4:8], ip4)
+	}
+	c.Write(reply)
+}
@@ -0,0 +1,83 @@
+package socks5
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestReadUntilNull(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"ASCII then NUL", "alice\x00rest", "alice", false},
+		{"empty before NUL", "\x00rest", "", false},
+		{"no NUL", "alice", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readUntilNull(strings.NewReader(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("readUntilNull() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readUntilNull() unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("readUntilNull() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendSocks4Reply(t *testing.T) {
+	tests := []struct {
+		name string
+		cd   byte
+		ip   net.IP
+		port uint16
+		want []byte
+	}{
+		{
+			name: "granted with address",
+			cd:   socks4RequestGranted,
+			ip:   net.IPv4(127, 0, 0, 1),
+			port: 8080,
+			want: []byte{0, socks4RequestGranted, 0x1F, 0x90, 127, 0, 0, 1},
+		},
+		{
+			name: "failed with no address",
+			cd:   socks4RequestFailed,
+			ip:   nil,
+			port: 0,
+			want: []byte{0, socks4RequestFailed, 0, 0, 0, 0, 0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+
+			go sendSocks4Reply(server, tt.cd, tt.ip, tt.port)
+
+			got := make([]byte, 8)
+			if _, err := io.ReadFull(client, got); err != nil {
+				t.Fatalf("ReadFull: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("sendSocks4Reply() wrote %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package socks5
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	IPv4       = 1
+	DomainName = 3
+	IPv6       = 4
+
+	Connect   = 1
+	Bind      = 2
+	Associate = 3
+)
+
+const (
+	succeeded uint8 = iota
+	serverFailure
+	notAllowed
+	networkUnreachable
+	hostUnreachable
+	connectionRefused
+	TTLExpired
+	commandNotSupported
+	addrTypeNotSupported
+)
+
+func (s *Server) handleNewConn(ctx context.Context, c net.Conn) {
+	buf := make([]byte, 2)
+	if _, err := c.Read(buf); err != nil {
+		s.config.Logger.Println("negotiation err", err)
+		c.Close()
+		return
+	}
+	version := buf[0]
+	if version == 4 {
+		s.handleSocks4(ctx, c, buf[1])
+		return
+	}
+	if version != 5 {
+		s.config.Logger.Println("unsupported protocol version", version)
+		c.Close()
+		return
+	}
+	nMethods := buf[1]
+
+	methods := make([]byte, nMethods)
+	if n, err := c.Read(methods); n != int(nMethods) || err != nil {
+		s.config.Logger.Println("wrong method")
+		c.Close()
+		return
+	}
+
+	var chosen Authenticator
+	for _, auth := range s.config.Authenticators {
+		for _, m := range methods {
+			if m == auth.GetCode() {
+				chosen = auth
+				break
+			}
+		}
+		if chosen != nil {
+			break
+		}
+	}
+
+	if chosen == nil {
+		c.Write([]byte{5, noAcceptableMethods})
+		c.Close()
+		return
+	}
+
+	authCtx, err := chosen.Authenticate(c, c)
+	if err != nil {
+		s.config.Logger.Println("authentication failed", err)
+		c.Close()
+		return
+	}
+
+	s.handleRequest(ctx, c, authCtx)
+}
+
+func (s *Server) handleRequest(ctx context.Context, c net.Conn, authCtx *AuthContext) {
+	/*
+		The SOCKS request is formed as follows:
+		+----+-----+-------+------+----------+----------+
+		|VER | CMD |  RSV  | ATYP | DST.ADDR | DST.PORT |
+		+----+-----+-------+------+----------+----------+
+		| 1  |  1  | X'00' |  1   | Variable |    2     |
+		+----+-----+-------+------+----------+----------+
+	*/
+	header := make([]byte, 3)
+
+	_, err := c.Read(header)
+
+	if err != nil {
+		s.config.Logger.Println("illegal request", err)
+		c.Close()
+		return
+	}
+
+	switch header[1] {
+	case Connect:
+		s.handleConnect(ctx, c, authCtx)
+	case Bind:
+		s.handleBind(ctx, c, authCtx)
+	case Associate:
+		s.handleUDP(ctx, c, authCtx)
+	default:
+		sendReply(c, commandNotSupported)
+		c.Close()
+	}
+}
+
+// readAddr parses the ATYP/DST.ADDR/DST.PORT portion shared by SOCKS5
+// requests and UDP datagram headers.
+func readAddr(r io.Reader) (atyp uint8, host string, port uint16, err error) {
+	addrType := make([]byte, 1)
+	if _, err = io.ReadFull(r, addrType); err != nil {
+		return 0, "", 0, err
+	}
+	atyp = addrType[0]
+
+	switch atyp {
+	case IPv4:
+		ip := make(net.IP, net.IPv4len)
+		if _, err = io.ReadFull(r, ip); err != nil {
+			return 0, "", 0, err
+		}
+		host = ip.String()
+	case IPv6:
+		ip := make(net.IP, net.IPv6len)
+		if _, err = io.ReadFull(r, ip); err != nil {
+			return 0, "", 0, err
+		}
+		host = ip.String()
+	case DomainName:
+		var domainLen uint8
+		if err = binary.Read(r, binary.BigEndian, &domainLen); err != nil {
+			return 0, "", 0, err
+		}
+		domain := make([]byte, domainLen)
+		if _, err = io.ReadFull(r, domain); err != nil {
+			return 0, "", 0, err
+		}
+		host = string(domain)
+	default:
+		return 0, "", 0, errors.New("address type not supported")
+	}
+
+	if err = binary.Read(r, binary.BigEndian, &port); err != nil {
+		return 0, "", 0, err
+	}
+	return atyp, host, port, nil
+}
+
+func sendReply(c net.Conn, rep uint8) {
+	localAddr := c.LocalAddr().String()
+	localHost, localPort, _ := net.SplitHostPort(localAddr)
+	nPort, _ := strconv.Atoi(localPort)
+	sendAddrReply(c, rep, net.ParseIP(localHost), uint16(nPort))
+}
+
+// sendAddrReply sends a SOCKS5 reply carrying an arbitrary BND.ADDR/BND.PORT,
+// for replies (UDP ASSOCIATE, BIND) that don't describe the local address of
+// the connection they're sent on.
+func sendAddrReply(c net.Conn, rep uint8, ip net.IP, port uint16) {
+	reply := []byte{5, rep, 0, IPv4}
+	addrBytes := ip.To4()
+	if addrBytes == nil {
+		reply[3] = IPv6
+		addrBytes = ip.To16()
+	}
+	reply = append(reply, addrBytes...)
+	portBytes := make([]byte, 2)
+	binary.BigEndian.PutUint16(portBytes, port)
+	reply = append(reply, portBytes...)
+	c.Write(reply)
+}
+
+// resolveAndAllow resolves a DomainName DST.ADDR, if any, and consults the
+// configured RuleSet for command/host/port. On denial or resolution failure
+// it sends the appropriate reply itself and returns a non-nil error.
+func (s *Server) resolveAndAllow(ctx context.Context, c net.Conn, command uint8, atyp uint8, host string, port uint16, authCtx *AuthContext) (context.Context, net.IP, error) {
+	ip := net.ParseIP(host)
+	var err error
+	if atyp == DomainName {
+		ctx, ip, err = s.config.Resolver.Resolve(ctx, host)
+		if err != nil {
+			s.config.Logger.Println("fail to resolve", host, err)
+			sendReply(c, hostUnreachable)
+			return ctx, nil, err
+		}
+	}
+
+	req := &Request{Command: command, DestAddr: ip, DestPort: port, DestHost: host, AuthContext: authCtx}
+	var allowed bool
+	if ctx, allowed = s.config.Rules.Allow(ctx, req); !allowed {
+		sendReply(c, notAllowed)
+		return ctx, nil, errors.New("request denied by rule set")
+	}
+
+	return ctx, ip, nil
+}
+
+func (s *Server) doConnect(ctx context.Context, c net.Conn, authCtx *AuthContext) (proxyConn net.Conn, err error) {
+	atyp, host, port, err := readAddr(c)
+	if err != nil {
+		sendReply(c, addrTypeNotSupported)
+		return nil, err
+	}
+
+	ctx, ip, err := s.resolveAndAllow(ctx, c, Connect, atyp, host, port, authCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := net.JoinHostPort(ip.String(), strconv.Itoa(int(port)))
+
+	dialCtx, cancel := context.WithTimeout(ctx, s.config.DialTimeout)
+	defer cancel()
+
+	// cancel the dial early if the client hangs up on the control
+	// connection while it's in flight.
+	stop := make(chan struct{})
+	buffered := make(chan []byte, 1)
+	go watchForClose(c, cancel, stop, buffered)
+	proxyConn, err = s.config.Dial(dialCtx, "tcp", addr)
+	close(stop)
+	pending := <-buffered // wait for the watcher to stop touching c's read deadline
+
+	if err != nil {
+		s.config.Logger.Println("fail to connect to", addr, err)
+		sendReply(c, mapDialError(err))
+		return nil, err
+	}
+
+	// RFC 1928 permits a client to start sending data right after the
+	// CONNECT request without waiting for the reply; watchForClose may have
+	// read the start of that data off c while the dial was in flight, so
+	// replay it to proxyConn before the relay takes over.
+	if len(pending) > 0 {
+		if _, err = proxyConn.Write(pending); err != nil {
+			s.config.Logger.Println("fail to forward buffered data to", addr, err)
+			proxyConn.Close()
+			sendReply(c, serverFailure)
+			return nil, err
+		}
+	}
+
+	sendReply(c, succeeded)
+	return proxyConn, nil
+}
+
+// watchForClose polls c for a closed/errored read while a dial is in
+// flight and calls cancel if one is observed. It stops as soon as stop is
+// closed, restores c's read deadline, and sends on buffered exactly once —
+// nil if c's normal reads should resume as-is, or any bytes read off c in
+// the meantime so the caller can replay them rather than drop them.
+func watchForClose(c net.Conn, cancel context.CancelFunc, stop <-chan struct{}, buffered chan<- []byte) {
+	defer c.SetReadDeadline(time.Time{})
+
+	one := make([]byte, 1)
+	for {
+		select {
+		case <-stop:
+			buffered <- nil
+			return
+		default:
+		}
+
+		c.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, err := c.Read(one)
+		if err == nil {
+			buffered <- one[:n]
+			return
+		}
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			continue
+		}
+		cancel()
+		buffered <- nil
+		return
+	}
+}
+
+// mapDialError maps a dial failure to the closest SOCKS5 reply code,
+// rather than always reporting hostUnreachable.
+func mapDialError(err error) uint8 {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return TTLExpired
+	}
+
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		switch errno {
+		case syscall.ECONNREFUSED:
+			return connectionRefused
+		case syscall.ENETUNREACH:
+			return networkUnreachable
+		case syscall.EHOSTUNREACH:
+			return hostUnreachable
+		}
+	}
+
+	return hostUnreachable
+}
+
+func (s *Server) handleConnect(ctx context.Context, c net.Conn, authCtx *AuthContext) {
+	proxyConn, err := s.doConnect(ctx, c, authCtx)
+	if err != nil {
+		c.Close()
+		return
+	}
+	go relay(c, proxyConn)
+	go relay(proxyConn, c)
+}
+
+func relay(in, out net.Conn) {
+	if _, err := io.Copy(in, out); err != nil {
+		log.Println("copy error", err)
+	}
+	in.Close() // will trigger an error in the other relay which will call out.Close()
+}
+
+// handleBind implements the RFC 1928 BIND command for FTP-style reverse
+// connections: a listener is opened on the server's outbound interface and
+// its address returned in the first reply, then a single peer connection is
+// accepted within BindAcceptTimeout and its address returned in the second
+// reply before relaying begins.
+func (s *Server) handleBind(ctx context.Context, c net.Conn, authCtx *AuthContext) {
+	if !s.config.EnableBind {
+		sendReply(c, notAllowed)
+		c.Close()
+		return
+	}
+
+	atyp, dstHost, dstPort, err := readAddr(c)
+	if err != nil {
+		sendReply(c, addrTypeNotSupported)
+		c.Close()
+		return
+	}
+
+	_, expected, err := s.resolveAndAllow(ctx, c, Bind, atyp, dstHost, dstPort, authCtx)
+	if err != nil {
+		c.Close()
+		return
+	}
+
+	bindAddr := &net.TCPAddr{IP: s.config.BindIP}
+	l, err := net.ListenTCP("tcp", bindAddr)
+	if err != nil {
+		s.config.Logger.Println("bind: failed to listen", err)
+		sendReply(c, serverFailure)
+		c.Close()
+		return
+	}
+	defer l.Close()
+
+	bnd := l.Addr().(*net.TCPAddr)
+	sendAddrReply(c, succeeded, bnd.IP, uint16(bnd.Port))
+
+	l.SetDeadline(time.Now().Add(s.config.BindAcceptTimeout))
+	peer, err := l.Accept()
+	if err != nil {
+		s.config.Logger.Println("bind: accept timed out", err)
+		sendReply(c, hostUnreachable)
+		c.Close()
+		return
+	}
+
+	if expected != nil && !expected.IsUnspecified() {
+		peerAddr := peer.RemoteAddr().(*net.TCPAddr)
+		if !peerAddr.IP.Equal(expected) {
+			s.config.Logger.Println("bind: peer address mismatch", peerAddr.IP, "!=", expected)
+			sendReply(c, notAllowed)
+			peer.Close()
+			c.Close()
+			return
+		}
+	}
+
+	peerAddr := peer.RemoteAddr().(*net.TCPAddr)
+	sendAddrReply(c, succeeded, peerAddr.IP, uint16(peerAddr.Port))
+
+	go relay(c, peer)
+	go relay(peer, c)
+}
@@ -0,0 +1,74 @@
+package socks5
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestReadAddr(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		wantAtyp uint8
+		wantHost string
+		wantPort uint16
+		wantErr  bool
+	}{
+		{
+			name:     "IPv4",
+			input:    []byte{IPv4, 127, 0, 0, 1, 0x1F, 0x90},
+			wantAtyp: IPv4,
+			wantHost: "127.0.0.1",
+			wantPort: 8080,
+		},
+		{
+			name: "IPv6",
+			input: append(append([]byte{IPv6}, net.ParseIP("::1").To16()...), 0x00, 0x50),
+			wantAtyp: IPv6,
+			wantHost: "::1",
+			wantPort: 80,
+		},
+		{
+			name:     "DomainName",
+			input:    append([]byte{DomainName, 11}, append([]byte("example.com"), 0x00, 0x50)...),
+			wantAtyp: DomainName,
+			wantHost: "example.com",
+			wantPort: 80,
+		},
+		{
+			name:    "unsupported ATYP",
+			input:   []byte{0x02, 0, 0},
+			wantErr: true,
+		},
+		{
+			name:    "truncated IPv4",
+			input:   []byte{IPv4, 127, 0, 0},
+			wantErr: true,
+		},
+		{
+			name:    "truncated port",
+			input:   []byte{IPv4, 127, 0, 0, 1, 0x1F},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atyp, host, port, err := readAddr(bytes.NewReader(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("readAddr() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readAddr() unexpected error: %v", err)
+			}
+			if atyp != tt.wantAtyp || host != tt.wantHost || port != tt.wantPort {
+				t.Errorf("readAddr() = (%v, %q, %v), want (%v, %q, %v)",
+					atyp, host, port, tt.wantAtyp, tt.wantHost, tt.wantPort)
+			}
+		})
+	}
+}
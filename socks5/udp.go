@@ -0,0 +1,172 @@
+package socks5
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+)
+
+// handleUDP implements RFC 1928 UDP ASSOCIATE: a dedicated relay socket is
+// bound on an ephemeral port and its address is returned to the client; the
+// controlling TCP connection is then held open and, once it closes, the
+// relay and all state tied to it are torn down.
+func (s *Server) handleUDP(ctx context.Context, c net.Conn, authCtx *AuthContext) {
+	// the client's DST.ADDR/DST.PORT in the ASSOCIATE request is advisory
+	// (RFC 1928 allows it to be left zeroed), so a zeroed one resolves to
+	// the unspecified address and is still run past the RuleSet.
+	atyp, host, port, err := readAddr(c)
+	if err != nil {
+		s.config.Logger.Println("udp associate: bad request", err)
+		sendReply(c, addrTypeNotSupported)
+		c.Close()
+		return
+	}
+
+	if _, _, err := s.resolveAndAllow(ctx, c, Associate, atyp, host, port, authCtx); err != nil {
+		c.Close()
+		return
+	}
+
+	relay, err := net.ListenUDP("udp", &net.UDPAddr{IP: s.config.BindIP})
+	if err != nil {
+		s.config.Logger.Println("udp associate: failed to bind relay socket", err)
+		sendReply(c, serverFailure)
+		c.Close()
+		return
+	}
+
+	sendUDPReply(c, relay.LocalAddr().(*net.UDPAddr))
+
+	// only datagrams from the host that issued the associate are relayed.
+	clientHost, _, _ := net.SplitHostPort(c.RemoteAddr().String())
+	go s.serveUDPAssociation(ctx, relay, clientHost, authCtx)
+
+	io.Copy(ioutil.Discard, c)
+	relay.Close()
+	c.Close()
+}
+
+// serveUDPAssociation pumps datagrams on a single unconnected relay socket:
+// packets from the associated client are unwrapped and forwarded to their
+// DST.ADDR, and replies from those destinations are wrapped back up in a
+// SOCKS5 UDP header and sent to the client. It exits once relay is closed.
+//
+// The ASSOCIATE handshake's own DST.ADDR/DST.PORT is advisory per RFC 1928
+// and frequently zeroed, so the RuleSet is consulted here, per datagram,
+// against each one's real target rather than only once up front.
+func (s *Server) serveUDPAssociation(ctx context.Context, relay *net.UDPConn, clientHost string, authCtx *AuthContext) {
+	var clientAddr *net.UDPAddr
+
+	buf := make([]byte, 65507)
+	for {
+		n, from, err := relay.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		if from.IP.String() == clientHost {
+			clientAddr = from
+
+			atyp, host, port, data, err := parseUDPDatagram(buf[:n])
+			if err != nil {
+				s.config.Logger.Println("udp associate: dropping client datagram", err)
+				continue
+			}
+			ip, allowed := s.resolveAndAllowUDP(ctx, atyp, host, port, authCtx)
+			if !allowed {
+				continue
+			}
+			dst, err := net.ResolveUDPAddr("udp", net.JoinHostPort(ip.String(), strconv.Itoa(int(port))))
+			if err != nil {
+				s.config.Logger.Println("udp associate: bad target address", err)
+				continue
+			}
+			if _, err := relay.WriteToUDP(data, dst); err != nil {
+				s.config.Logger.Println("udp associate: forward failed", err)
+			}
+			continue
+		}
+
+		if clientAddr == nil {
+			// a reply arrived before we ever saw a datagram from the client
+			continue
+		}
+		if _, err := relay.WriteToUDP(wrapUDPDatagram(from, buf[:n]), clientAddr); err != nil {
+			s.config.Logger.Println("udp associate: reply failed", err)
+		}
+	}
+}
+
+// resolveAndAllowUDP is serveUDPAssociation's analogue of resolveAndAllow:
+// it resolves a DomainName DST.ADDR and consults the RuleSet, but — unlike
+// the TCP paths — has no reply to send on denial, so it just reports
+// whether the datagram may be forwarded.
+func (s *Server) resolveAndAllowUDP(ctx context.Context, atyp uint8, host string, port uint16, authCtx *AuthContext) (net.IP, bool) {
+	ip := net.ParseIP(host)
+	var err error
+	if atyp == DomainName {
+		_, ip, err = s.config.Resolver.Resolve(ctx, host)
+		if err != nil {
+			s.config.Logger.Println("udp associate: fail to resolve", host, err)
+			return nil, false
+		}
+	}
+
+	req := &Request{Command: Associate, DestAddr: ip, DestPort: port, DestHost: host, AuthContext: authCtx}
+	if _, allowed := s.config.Rules.Allow(ctx, req); !allowed {
+		return nil, false
+	}
+	return ip, true
+}
+
+// parseUDPDatagram splits a client-sent SOCKS5 UDP packet into its
+// destination and payload.
+//
+//	+----+------+------+----------+----------+----------+
+//	|RSV | FRAG | ATYP | DST.ADDR | DST.PORT |   DATA   |
+//	+----+------+------+----------+----------+----------+
+//	| 2  |  1   |  1   | Variable |    2     | Variable |
+//	+----+------+------+----------+----------+----------+
+func parseUDPDatagram(b []byte) (atyp uint8, host string, port uint16, data []byte, err error) {
+	if len(b) < 4 {
+		return 0, "", 0, nil, errors.New("udp datagram too short")
+	}
+	if b[2] != 0 {
+		return 0, "", 0, nil, errors.New("fragmentation not supported")
+	}
+
+	r := bytes.NewReader(b[3:])
+	if atyp, host, port, err = readAddr(r); err != nil {
+		return 0, "", 0, nil, err
+	}
+	data = b[len(b)-r.Len():]
+	return atyp, host, port, data, nil
+}
+
+// wrapUDPDatagram re-wraps a reply from addr in the same header format
+// before it is relayed back to the client.
+func wrapUDPDatagram(addr *net.UDPAddr, data []byte) []byte {
+	header := []byte{0, 0, 0, IPv4}
+	ip := addr.IP.To4()
+	if ip == nil {
+		header[3] = IPv6
+		ip = addr.IP.To16()
+	}
+	header = append(header, ip...)
+	portBytes := make([]byte, 2)
+	binary.BigEndian.PutUint16(portBytes, uint16(addr.Port))
+	header = append(header, portBytes...)
+	return append(header, data...)
+}
+
+// sendUDPReply sends the second SOCKS5 reply for an ASSOCIATE request,
+// carrying the address of the dedicated relay socket rather than the
+// control connection's own local address.
+func sendUDPReply(c net.Conn, addr *net.UDPAddr) {
+	sendAddrReply(c, succeeded, addr.IP, uint16(addr.Port))
+}
@@ -0,0 +1,115 @@
+package socks5
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestParseUDPDatagram(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		wantAtyp uint8
+		wantHost string
+		wantPort uint16
+		wantData []byte
+		wantErr  bool
+	}{
+		{
+			name:     "IPv4 with data",
+			input:    []byte{0, 0, 0, IPv4, 127, 0, 0, 1, 0x1F, 0x90, 'h', 'i'},
+			wantAtyp: IPv4,
+			wantHost: "127.0.0.1",
+			wantPort: 8080,
+			wantData: []byte("hi"),
+		},
+		{
+			name:     "DomainName with no data",
+			input:    append([]byte{0, 0, 0, DomainName, 11}, append([]byte("example.com"), 0x00, 0x50)...),
+			wantAtyp: DomainName,
+			wantHost: "example.com",
+			wantPort: 80,
+			wantData: []byte{},
+		},
+		{
+			name:    "too short",
+			input:   []byte{0, 0, 0},
+			wantErr: true,
+		},
+		{
+			name:    "fragmented",
+			input:   []byte{0, 0, 1, IPv4, 127, 0, 0, 1, 0, 80},
+			wantErr: true,
+		},
+		{
+			name:    "truncated address",
+			input:   []byte{0, 0, 0, IPv4, 127, 0, 0},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atyp, host, port, data, err := parseUDPDatagram(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseUDPDatagram() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseUDPDatagram() unexpected error: %v", err)
+			}
+			if atyp != tt.wantAtyp || host != tt.wantHost || port != tt.wantPort {
+				t.Errorf("parseUDPDatagram() = (%v, %q, %v), want (%v, %q, %v)",
+					atyp, host, port, tt.wantAtyp, tt.wantHost, tt.wantPort)
+			}
+			if !bytes.Equal(data, tt.wantData) {
+				t.Errorf("parseUDPDatagram() data = %q, want %q", data, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestWrapUDPDatagram(t *testing.T) {
+	tests := []struct {
+		name string
+		addr *net.UDPAddr
+		data []byte
+		want []byte
+	}{
+		{
+			name: "IPv4",
+			addr: &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080},
+			data: []byte("hi"),
+			want: []byte{0, 0, 0, IPv4, 127, 0, 0, 1, 0x1F, 0x90, 'h', 'i'},
+		},
+		{
+			name: "IPv6",
+			addr: &net.UDPAddr{IP: net.ParseIP("::1"), Port: 80},
+			data: []byte("x"),
+			want: append(append([]byte{0, 0, 0, IPv6}, net.ParseIP("::1").To16()...), 0, 80, 'x'),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wrapUDPDatagram(tt.addr, tt.data)
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("wrapUDPDatagram() = %v, want %v", got, tt.want)
+			}
+
+			// round-trip through parseUDPDatagram to confirm the wrapped
+			// header is read back exactly as wrapped.
+			_, host, port, data, err := parseUDPDatagram(got)
+			if err != nil {
+				t.Fatalf("parseUDPDatagram(wrapUDPDatagram(...)) error: %v", err)
+			}
+			if host != tt.addr.IP.String() || port != uint16(tt.addr.Port) || !bytes.Equal(data, tt.data) {
+				t.Errorf("round-trip = (%q, %v, %q), want (%q, %v, %q)",
+					host, port, data, tt.addr.IP.String(), tt.addr.Port, tt.data)
+			}
+		})
+	}
+}